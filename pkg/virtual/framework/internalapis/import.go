@@ -25,9 +25,11 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apiserver/pkg/cel/openapi/resolver"
 	endpointsopenapi "k8s.io/apiserver/pkg/endpoints/openapi"
 	genericapiserver "k8s.io/apiserver/pkg/server"
 	"k8s.io/apiserver/pkg/util/openapi"
+	"k8s.io/klog/v2"
 	"k8s.io/kube-openapi/pkg/builder"
 	"k8s.io/kube-openapi/pkg/common"
 	"k8s.io/kube-openapi/pkg/util"
@@ -36,15 +38,60 @@ import (
 	"github.com/kcp-dev/kcp/pkg/crdpuller"
 )
 
-// InternalAPI describes an API to be imported from some schemes and generated OpenAPI V2 definitions
+// InternalAPIVersion describes a single served version of an InternalAPI, mirroring the
+// per-version fields of apiextensionsv1.CustomResourceDefinitionVersion.
+type InternalAPIVersion struct {
+	Version  string
+	Instance runtime.Object
+	Served   bool
+	Storage  bool
+
+	// Subresources, if set, is copied verbatim into the generated APIResourceVersion and
+	// takes precedence over HasStatus/HasScale below. Use it for subresources that don't
+	// fit the convenience fields, e.g. /ephemeralcontainers on Pods.
+	Subresources *apiextensionsv1.CustomResourceSubresources
+
+	// HasStatus is a convenience for the common case of wanting a plain /status subresource.
+	HasStatus bool
+
+	// HasScale is a convenience for wanting a /scale subresource with ScaleSpec, or with the
+	// usual .spec.replicas/.status.replicas/.status.selector paths if ScaleSpec is nil.
+	HasScale  bool
+	ScaleSpec *apiextensionsv1.CustomResourceSubresourceScale
+}
+
+// InternalAPI describes an API to be imported from some schemes and generated OpenAPI V2 definitions.
+// It can have multiple Versions, the same way a CustomResourceDefinition can, so that built-in
+// APIs can evolve over time. Exactly one Version must have Storage set, unless the InternalAPI
+// is Virtual.
+//
+// Note: apisv1alpha1.APIResourceSchemaSpec has no conversion-webhook equivalent of
+// apiextensionsv1.CustomResourceConversion, so unlike a CustomResourceDefinition an InternalAPI
+// cannot declare a conversion strategy between its Versions.
 type InternalAPI struct {
 	Names        apiextensionsv1.CustomResourceDefinitionNames
 	GroupVersion schema.GroupVersion
-	Instance     runtime.Object
+	Versions     []InternalAPIVersion
 	ResourceSope apiextensionsv1.ResourceScope
-	HasStatus    bool
+
+	// Virtual marks an InternalAPI that is not backed by storage, such as the authorization
+	// and authentication review APIs. A Virtual InternalAPI is never the storage version,
+	// gets no status subresource from HasStatus (an explicit Subresources value is still
+	// copied as given), and has its generated APIResourceSchema annotated with
+	// VirtualAPIAnnotation.
+	//
+	// This package only produces that schema and annotation. Omitting list/watch and wiring
+	// a create-only REST storage for the resource is the responsibility of whatever turns an
+	// APIResourceSchema into REST storage, which is out of scope for this package and not
+	// implemented yet.
+	Virtual bool
 }
 
+// VirtualAPIAnnotation is set to "true" on the APIResourceSchema generated for a Virtual
+// InternalAPI, for a future REST storage layer to key off of. Nothing in this package reads
+// it yet.
+const VirtualAPIAnnotation = "internal.apis.kcp.io/virtual"
+
 // KCPInternalAPIs provides a list of InternalAPI for the APIs that are part of the KCP scheme and will be there in every KCP workspace
 var KCPInternalAPIs = []InternalAPI{
 	{
@@ -54,9 +101,10 @@ var KCPInternalAPIs = []InternalAPI{
 			Kind:     "Namespace",
 		},
 		GroupVersion: schema.GroupVersion{Group: "", Version: "v1"},
-		Instance:     &corev1.Namespace{},
+		Versions: []InternalAPIVersion{
+			{Version: "v1", Instance: &corev1.Namespace{}, Served: true, Storage: true, HasStatus: true},
+		},
 		ResourceSope: apiextensionsv1.ClusterScoped,
-		HasStatus:    true,
 	},
 	{
 		Names: apiextensionsv1.CustomResourceDefinitionNames{
@@ -65,7 +113,9 @@ var KCPInternalAPIs = []InternalAPI{
 			Kind:     "ConfigMap",
 		},
 		GroupVersion: schema.GroupVersion{Group: "", Version: "v1"},
-		Instance:     &corev1.ConfigMap{},
+		Versions: []InternalAPIVersion{
+			{Version: "v1", Instance: &corev1.ConfigMap{}, Served: true, Storage: true},
+		},
 		ResourceSope: apiextensionsv1.NamespaceScoped,
 	},
 	{
@@ -75,7 +125,9 @@ var KCPInternalAPIs = []InternalAPI{
 			Kind:     "Secret",
 		},
 		GroupVersion: schema.GroupVersion{Group: "", Version: "v1"},
-		Instance:     &corev1.Secret{},
+		Versions: []InternalAPIVersion{
+			{Version: "v1", Instance: &corev1.Secret{}, Served: true, Storage: true},
+		},
 		ResourceSope: apiextensionsv1.NamespaceScoped,
 	},
 	{
@@ -85,13 +137,36 @@ var KCPInternalAPIs = []InternalAPI{
 			Kind:     "ServiceAccount",
 		},
 		GroupVersion: schema.GroupVersion{Group: "", Version: "v1"},
-		Instance:     &corev1.ServiceAccount{},
+		Versions: []InternalAPIVersion{
+			{Version: "v1", Instance: &corev1.ServiceAccount{}, Served: true, Storage: true},
+		},
 		ResourceSope: apiextensionsv1.NamespaceScoped,
 	},
 }
 
-func createAPIResourceSchemas(schemes []*runtime.Scheme, openAPIDefinitionsGetters []common.GetOpenAPIDefinitions, defs ...InternalAPI) ([]*apisv1alpha1.APIResourceSchema, error) {
-	config := genericapiserver.DefaultOpenAPIConfig(func(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
+// APISource groups a set of InternalAPIs together with the schemes and OpenAPI definitions
+// needed to build their schemas, so that heterogeneous sources (core, rbac, authorization,
+// custom addons) can each bring their own without clashing with one another.
+type APISource struct {
+	Schemes                   []*runtime.Scheme
+	OpenAPIDefinitionsGetters []common.GetOpenAPIDefinitions
+	APIs                      []InternalAPI
+}
+
+func createAPIResourceSchemas(sources ...APISource) ([]*apisv1alpha1.APIResourceSchema, error) {
+	var apis []*apisv1alpha1.APIResourceSchema
+	for _, source := range sources {
+		sourceAPIs, err := createAPIResourceSchemasForSource(source.Schemes, source.OpenAPIDefinitionsGetters, source.APIs...)
+		if err != nil {
+			return nil, err
+		}
+		apis = append(apis, sourceAPIs...)
+	}
+	return apis, nil
+}
+
+func createAPIResourceSchemasForSource(schemes []*runtime.Scheme, openAPIDefinitionsGetters []common.GetOpenAPIDefinitions, defs ...InternalAPI) ([]*apisv1alpha1.APIResourceSchema, error) {
+	combinedGetter := func(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 		result := make(map[string]common.OpenAPIDefinition)
 
 		for _, openAPIDefinitionsGetter := range openAPIDefinitionsGetters {
@@ -101,11 +176,20 @@ func createAPIResourceSchemas(schemes []*runtime.Scheme, openAPIDefinitionsGette
 		}
 
 		return result
-	}, endpointsopenapi.NewDefinitionNamer(schemes...))
+	}
+	config := genericapiserver.DefaultOpenAPIConfig(combinedGetter, endpointsopenapi.NewDefinitionNamer(schemes...))
+
+	// v3Resolver produces CEL-validatable, structural schemas straight from the combined
+	// OpenAPI definitions, without round-tripping through an OpenAPI v2 proto model. It is
+	// preferred over the v2 path below, which is kept only as a fallback for GVKs it can't
+	// resolve.
+	v3Resolver := resolver.NewDefinitionsSchemaResolver(combinedGetter, schemes...)
 
 	var canonicalTypeNames []string
 	for _, def := range defs {
-		canonicalTypeNames = append(canonicalTypeNames, util.GetCanonicalTypeName(def.Instance))
+		for _, v := range def.Versions {
+			canonicalTypeNames = append(canonicalTypeNames, util.GetCanonicalTypeName(v.Instance))
+		}
 	}
 	swagger, err := builder.BuildOpenAPIDefinitionsForResources(config, canonicalTypeNames...)
 	if err != nil {
@@ -124,39 +208,89 @@ func createAPIResourceSchemas(schemes []*runtime.Scheme, openAPIDefinitionsGette
 
 	var apis []*apisv1alpha1.APIResourceSchema
 	for _, def := range defs {
-		gvk := def.GroupVersion.WithKind(def.Names.Kind)
-		var schemaProps apiextensionsv1.JSONSchemaProps
-		errs := crdpuller.Convert(modelsByGKV[gvk], &schemaProps)
-		if len(errs) > 0 {
-			return nil, errors.NewAggregate(errs)
-		}
 		group := def.GroupVersion.Group
 		if group == "" {
 			group = "core"
 		}
+		objectMeta := metav1.ObjectMeta{
+			Name: fmt.Sprintf("internal.%s.%s", def.Names.Plural, group),
+		}
+		if def.Virtual {
+			// A future REST storage layer can key off this annotation to omit list/watch
+			// and register a create-only storage for the resource instead of the usual
+			// CRUD+list+watch one; this package stops at marking the schema.
+			objectMeta.Annotations = map[string]string{VirtualAPIAnnotation: "true"}
+		}
 		spec := &apisv1alpha1.APIResourceSchema{
-			ObjectMeta: metav1.ObjectMeta{
-				Name: fmt.Sprintf("internal.%s.%s", def.Names.Plural, group),
-			},
+			ObjectMeta: objectMeta,
 			Spec: apisv1alpha1.APIResourceSchemaSpec{
 				Group: def.GroupVersion.Group,
 				Names: def.Names,
 				Scope: def.ResourceSope,
-				Versions: []apisv1alpha1.APIResourceVersion{
-					{
-						Name:    "v1",
-						Served:  true,
-						Storage: true,
-						Schema:  runtime.RawExtension{},
-					},
-				},
 			},
 		}
-		if def.HasStatus {
-			spec.Spec.Versions[0].Subresources.Status = &apiextensionsv1.CustomResourceSubresourceStatus{}
+
+		storageVersions := 0
+		for _, v := range def.Versions {
+			gvk := schema.GroupVersion{Group: def.GroupVersion.Group, Version: v.Version}.WithKind(def.Names.Kind)
+
+			var schemaProps apiextensionsv1.JSONSchemaProps
+			usedV3 := false
+			if v3Schema, err := v3Resolver.ResolveSchema(gvk); err != nil {
+				klog.V(4).InfoS("resolving v3 OpenAPI schema failed, falling back to v2", "gvk", gvk, "err", err)
+			} else if containsUnresolvedRef(v3Schema) {
+				klog.V(4).InfoS("v3 OpenAPI schema has an unresolved $ref; InternalAPI schemas carry no definitions to inline into, falling back to v2", "gvk", gvk)
+			} else {
+				schemaProps = *jsonSchemaPropsFromV3(v3Schema)
+				usedV3 = true
+			}
+			if !usedV3 {
+				model, ok := modelsByGKV[gvk]
+				if !ok {
+					return nil, fmt.Errorf("no OpenAPI v2 model found for %s; regenerate pkg/openapi to include it", gvk)
+				}
+				errs := crdpuller.Convert(model, &schemaProps)
+				if len(errs) > 0 {
+					return nil, errors.NewAggregate(errs)
+				}
+			}
+
+			// Virtual APIs are never persisted, so they can never be "the" storage version
+			// regardless of what the InternalAPIVersion literal says.
+			storage := v.Storage && !def.Virtual
+			if storage {
+				storageVersions++
+			}
+			apiVersion := apisv1alpha1.APIResourceVersion{
+				Name:    v.Version,
+				Served:  v.Served,
+				Storage: storage,
+				Schema:  runtime.RawExtension{},
+			}
+			switch {
+			case v.Subresources != nil:
+				apiVersion.Subresources = *v.Subresources
+			default:
+				if v.HasStatus && !def.Virtual {
+					// CustomResourceSubresourceStatus carries no configurable fields in
+					// apiextensions/v1, so there are no selectable status fields to
+					// populate from the OpenAPI schema here; this is just the marker
+					// that turns /status on.
+					apiVersion.Subresources.Status = &apiextensionsv1.CustomResourceSubresourceStatus{}
+				}
+				if v.HasScale {
+					apiVersion.Subresources.Scale = scaleSubresourceFor(v.ScaleSpec)
+				}
+			}
+			if err := apiVersion.SetSchema(&schemaProps); err != nil {
+				return nil, err
+			}
+
+			spec.Spec.Versions = append(spec.Spec.Versions, apiVersion)
 		}
-		if err := spec.Spec.Versions[0].SetSchema(&schemaProps); err != nil {
-			return nil, err
+
+		if !def.Virtual && storageVersions != 1 {
+			return nil, fmt.Errorf("InternalAPI %s must have exactly one storage version, got %d", def.Names.Kind, storageVersions)
 		}
 
 		apis = append(apis, spec)
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internalapis
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/kube-openapi/pkg/common"
+
+	apisv1alpha1 "github.com/kcp-dev/kcp/pkg/apis/apis/v1alpha1"
+	generatedopenapi "github.com/kcp-dev/kcp/pkg/openapi"
+)
+
+var (
+	registryLock sync.Mutex
+	registry     []APISource
+)
+
+// Register adds source to the set of APISources that will be present in every KCP workspace.
+// It is meant to be called from init() functions, including by out-of-tree packages that want
+// to contribute additional built-in APIs (e.g. RBAC, events, leases, coordination). A source
+// bundles its InternalAPIs together with the schemes and OpenAPI definitions needed to build
+// their schemas, because an InternalAPI with no way to resolve its schema isn't useful to
+// callers of All().
+func Register(source APISource) {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	registry = append(registry, source)
+}
+
+// All returns every APISource registered so far, including the core one for KCPInternalAPIs.
+func All() []APISource {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	out := make([]APISource, len(registry))
+	copy(out, registry)
+	return out
+}
+
+// CreateAllAPIResourceSchemas builds the APIResourceSchema objects for every APISource
+// registered so far, i.e. KCPInternalAPIs plus anything contributed by other packages'
+// init() functions through Register. This is the entry point out-of-tree packages and the
+// KCP server use to turn the registry into schemas without needing to know about
+// createAPIResourceSchemas.
+func CreateAllAPIResourceSchemas() ([]*apisv1alpha1.APIResourceSchema, error) {
+	return createAPIResourceSchemas(All()...)
+}
+
+// coreScheme carries the core/v1 types referenced by KCPInternalAPIs.
+var coreScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(corev1.AddToScheme(coreScheme))
+
+	Register(APISource{
+		Schemes:                   []*runtime.Scheme{coreScheme},
+		OpenAPIDefinitionsGetters: []common.GetOpenAPIDefinitions{generatedopenapi.GetOpenAPIDefinitions},
+		APIs:                      KCPInternalAPIs,
+	})
+}
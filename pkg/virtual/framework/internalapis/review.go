@@ -0,0 +1,119 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internalapis
+
+import (
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/kube-openapi/pkg/common"
+
+	generatedopenapi "github.com/kcp-dev/kcp/pkg/openapi"
+)
+
+// ReviewInternalAPIs are the non-persisted, create-only review APIs that let kubectl auth can-i
+// and impersonation tooling work uniformly inside every KCP workspace.
+var ReviewInternalAPIs = []InternalAPI{
+	{
+		Names: apiextensionsv1.CustomResourceDefinitionNames{
+			Plural:   "subjectaccessreviews",
+			Singular: "subjectaccessreview",
+			Kind:     "SubjectAccessReview",
+		},
+		GroupVersion: schema.GroupVersion{Group: "authorization.k8s.io", Version: "v1"},
+		Versions: []InternalAPIVersion{
+			{Version: "v1", Instance: &authorizationv1.SubjectAccessReview{}, Served: true},
+		},
+		ResourceSope: apiextensionsv1.ClusterScoped,
+		Virtual:      true,
+	},
+	{
+		Names: apiextensionsv1.CustomResourceDefinitionNames{
+			Plural:   "selfsubjectaccessreviews",
+			Singular: "selfsubjectaccessreview",
+			Kind:     "SelfSubjectAccessReview",
+		},
+		GroupVersion: schema.GroupVersion{Group: "authorization.k8s.io", Version: "v1"},
+		Versions: []InternalAPIVersion{
+			{Version: "v1", Instance: &authorizationv1.SelfSubjectAccessReview{}, Served: true},
+		},
+		ResourceSope: apiextensionsv1.ClusterScoped,
+		Virtual:      true,
+	},
+	{
+		Names: apiextensionsv1.CustomResourceDefinitionNames{
+			Plural:   "selfsubjectrulesreviews",
+			Singular: "selfsubjectrulesreview",
+			Kind:     "SelfSubjectRulesReview",
+		},
+		GroupVersion: schema.GroupVersion{Group: "authorization.k8s.io", Version: "v1"},
+		Versions: []InternalAPIVersion{
+			{Version: "v1", Instance: &authorizationv1.SelfSubjectRulesReview{}, Served: true},
+		},
+		ResourceSope: apiextensionsv1.ClusterScoped,
+		Virtual:      true,
+	},
+	{
+		Names: apiextensionsv1.CustomResourceDefinitionNames{
+			Plural:   "localsubjectaccessreviews",
+			Singular: "localsubjectaccessreview",
+			Kind:     "LocalSubjectAccessReview",
+		},
+		GroupVersion: schema.GroupVersion{Group: "authorization.k8s.io", Version: "v1"},
+		Versions: []InternalAPIVersion{
+			{Version: "v1", Instance: &authorizationv1.LocalSubjectAccessReview{}, Served: true},
+		},
+		ResourceSope: apiextensionsv1.NamespaceScoped,
+		Virtual:      true,
+	},
+	{
+		Names: apiextensionsv1.CustomResourceDefinitionNames{
+			Plural:   "tokenreviews",
+			Singular: "tokenreview",
+			Kind:     "TokenReview",
+		},
+		GroupVersion: schema.GroupVersion{Group: "authentication.k8s.io", Version: "v1"},
+		Versions: []InternalAPIVersion{
+			{Version: "v1", Instance: &authenticationv1.TokenReview{}, Served: true},
+		},
+		ResourceSope: apiextensionsv1.ClusterScoped,
+		Virtual:      true,
+	},
+}
+
+// reviewScheme carries the authorization.k8s.io and authentication.k8s.io review types
+// referenced by ReviewInternalAPIs.
+var reviewScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(authorizationv1.AddToScheme(reviewScheme))
+	utilruntime.Must(authenticationv1.AddToScheme(reviewScheme))
+
+	// generatedopenapi.GetOpenAPIDefinitions must cover authorization.k8s.io/v1 and
+	// authentication.k8s.io/v1, i.e. pkg/openapi needs to have been regenerated (via the
+	// project's codegen target) with these review types included as openapi-gen roots. If
+	// it hasn't, createAPIResourceSchemas now fails loudly with a missing-model error for
+	// these GVKs instead of silently producing an empty schema.
+	Register(APISource{
+		Schemes:                   []*runtime.Scheme{reviewScheme},
+		OpenAPIDefinitionsGetters: []common.GetOpenAPIDefinitions{generatedopenapi.GetOpenAPIDefinitions},
+		APIs:                      ReviewInternalAPIs,
+	})
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internalapis
+
+import (
+	"encoding/json"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+// containsUnresolvedRef reports whether s, or anything nested inside it, still carries a $ref.
+// An APIResourceSchema version's schema has no definitions map for a $ref to resolve against,
+// and structural schemas forbid $ref outright, so a v3 schema the resolver left unbundled
+// cannot be translated as-is; callers should fall back to the OpenAPI v2 path instead.
+func containsUnresolvedRef(s *spec.Schema) bool {
+	if s == nil {
+		return false
+	}
+	if s.Ref.String() != "" {
+		return true
+	}
+	for _, prop := range s.Properties {
+		if containsUnresolvedRef(&prop) {
+			return true
+		}
+	}
+	if s.Items != nil && containsUnresolvedRef(s.Items.Schema) {
+		return true
+	}
+	if s.AdditionalProperties != nil && containsUnresolvedRef(s.AdditionalProperties.Schema) {
+		return true
+	}
+	for _, of := range append(append(append([]spec.Schema{}, s.OneOf...), s.AnyOf...), s.AllOf...) {
+		if containsUnresolvedRef(&of) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonSchemaPropsFromV3 translates an OpenAPI v3 *spec.Schema, as produced by the CEL
+// DefinitionsSchemaResolver, directly into apiextensionsv1.JSONSchemaProps. Unlike the
+// crdpuller.Convert path, which round-trips through an OpenAPI v2 proto model and loses
+// v3-only information, this preserves oneOf/anyOf/nullable, the numeric/length constraint
+// keywords, and the x-kubernetes-* vendor extensions CEL and server-side apply rely on, so
+// the result is CEL-validatable and structural. Callers must check containsUnresolvedRef
+// first: this function assumes s has already been fully resolved and does not itself handle
+// $ref.
+func jsonSchemaPropsFromV3(s *spec.Schema) *apiextensionsv1.JSONSchemaProps {
+	if s == nil {
+		return nil
+	}
+
+	out := &apiextensionsv1.JSONSchemaProps{
+		ID:               s.ID,
+		Title:            s.Title,
+		Description:      s.Description,
+		Format:           s.Format,
+		Nullable:         s.Nullable,
+		Required:         s.Required,
+		Maximum:          s.Maximum,
+		ExclusiveMaximum: s.ExclusiveMaximum,
+		Minimum:          s.Minimum,
+		ExclusiveMinimum: s.ExclusiveMinimum,
+		MaxLength:        s.MaxLength,
+		MinLength:        s.MinLength,
+		Pattern:          s.Pattern,
+		MaxItems:         s.MaxItems,
+		MinItems:         s.MinItems,
+		UniqueItems:      s.UniqueItems,
+		MultipleOf:       s.MultipleOf,
+		MaxProperties:    s.MaxProperties,
+		MinProperties:    s.MinProperties,
+	}
+	if len(s.Type) > 0 {
+		out.Type = s.Type[0]
+	}
+	if s.Default != nil {
+		out.Default = toJSON(s.Default)
+	}
+	for _, e := range s.Enum {
+		out.Enum = append(out.Enum, toJSON(e))
+	}
+	if v, ok := s.Extensions.GetBool("x-kubernetes-int-or-string"); ok {
+		out.XIntOrString = v
+	}
+	if v, ok := s.Extensions.GetBool("x-kubernetes-preserve-unknown-fields"); ok {
+		out.XPreserveUnknownFields = &v
+	}
+	if v, ok := s.Extensions.GetBool("x-kubernetes-embedded-resource"); ok {
+		out.XEmbeddedResource = v
+	}
+	if v, ok := s.Extensions.GetString("x-kubernetes-list-type"); ok {
+		out.XListType = &v
+	}
+	if v, ok := s.Extensions.GetStringSlice("x-kubernetes-list-map-keys"); ok {
+		out.XListMapKeys = v
+	}
+	if v, ok := s.Extensions.GetString("x-kubernetes-map-type"); ok {
+		out.XMapType = &v
+	}
+	if len(s.Properties) > 0 {
+		out.Properties = map[string]apiextensionsv1.JSONSchemaProps{}
+		for name, prop := range s.Properties {
+			prop := prop
+			out.Properties[name] = *jsonSchemaPropsFromV3(&prop)
+		}
+	}
+	if s.Items != nil && s.Items.Schema != nil {
+		out.Items = &apiextensionsv1.JSONSchemaPropsOrArray{
+			Schema: jsonSchemaPropsFromV3(s.Items.Schema),
+		}
+	}
+	if s.AdditionalProperties != nil {
+		out.AdditionalProperties = &apiextensionsv1.JSONSchemaPropsOrBool{
+			Allows: s.AdditionalProperties.Allows,
+			Schema: jsonSchemaPropsFromV3(s.AdditionalProperties.Schema),
+		}
+	}
+	for _, of := range s.OneOf {
+		of := of
+		out.OneOf = append(out.OneOf, *jsonSchemaPropsFromV3(&of))
+	}
+	for _, of := range s.AnyOf {
+		of := of
+		out.AnyOf = append(out.AnyOf, *jsonSchemaPropsFromV3(&of))
+	}
+	for _, of := range s.AllOf {
+		of := of
+		out.AllOf = append(out.AllOf, *jsonSchemaPropsFromV3(&of))
+	}
+	return out
+}
+
+// toJSON converts an arbitrary decoded JSON value (as found on spec.Schema's Default/Enum
+// fields) into the apiextensions v1 JSON wrapper type.
+func toJSON(v interface{}) *apiextensionsv1.JSON {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return &apiextensionsv1.JSON{Raw: raw}
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internalapis
+
+import (
+	apiserverinternalv1alpha1 "k8s.io/api/apiserverinternal/v1alpha1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	apiserverinternalinstall "k8s.io/apiserver/pkg/apis/apiserverinternal/install"
+	"k8s.io/kube-openapi/pkg/common"
+
+	generatedopenapi "github.com/kcp-dev/kcp/pkg/openapi"
+)
+
+// StorageVersionScheme carries the internal.apiserver.k8s.io types so that, alongside
+// StorageVersionInternalAPI, workspaces expose the same storage-version reporting surface
+// that kube-apiserver uses to coordinate CRD/built-in storage migrations.
+var StorageVersionScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(apiserverinternalinstall.Install(StorageVersionScheme))
+
+	// generatedopenapi.GetOpenAPIDefinitions must cover internal.apiserver.k8s.io/v1alpha1,
+	// i.e. pkg/openapi needs to have been regenerated (via the project's codegen target)
+	// with StorageVersion included as an openapi-gen root. If it hasn't,
+	// createAPIResourceSchemas now fails loudly with a missing-model error for this GVK
+	// instead of silently producing an empty schema.
+	Register(APISource{
+		Schemes:                   []*runtime.Scheme{StorageVersionScheme},
+		OpenAPIDefinitionsGetters: []common.GetOpenAPIDefinitions{generatedopenapi.GetOpenAPIDefinitions},
+		APIs:                      []InternalAPI{StorageVersionInternalAPI},
+	})
+}
+
+// StorageVersionInternalAPI registers internal.apiserver.k8s.io/v1alpha1 StorageVersion as a
+// built-in API present in every KCP workspace.
+var StorageVersionInternalAPI = InternalAPI{
+	Names: apiextensionsv1.CustomResourceDefinitionNames{
+		Plural:   "storageversions",
+		Singular: "storageversion",
+		Kind:     "StorageVersion",
+	},
+	GroupVersion: schema.GroupVersion{Group: "internal.apiserver.k8s.io", Version: "v1alpha1"},
+	Versions: []InternalAPIVersion{
+		{Version: "v1alpha1", Instance: &apiserverinternalv1alpha1.StorageVersion{}, Served: true, Storage: true, HasStatus: true},
+	},
+	ResourceSope: apiextensionsv1.ClusterScoped,
+}
@@ -0,0 +1,36 @@
+/*
+Copyright 2022 The KCP Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package internalapis
+
+import apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+// scaleSubresourceFor returns spec, falling back to the conventional Deployment/ReplicaSet-style
+// paths when the caller didn't provide one.
+func scaleSubresourceFor(spec *apiextensionsv1.CustomResourceSubresourceScale) *apiextensionsv1.CustomResourceSubresourceScale {
+	if spec != nil {
+		return spec
+	}
+	return &apiextensionsv1.CustomResourceSubresourceScale{
+		SpecReplicasPath:   ".spec.replicas",
+		StatusReplicasPath: ".status.replicas",
+		LabelSelectorPath:  pointerTo(".status.selector"),
+	}
+}
+
+func pointerTo(s string) *string {
+	return &s
+}